@@ -0,0 +1,143 @@
+// Package accesskey mints and validates tenant-scoped access-key/secret
+// pairs that sit in front of the object package's Store/Retrieve/List/Delete
+// operations, giving multi-tenant deployments scoped credentials without
+// exposing the underlying AWS credentials.
+package accesskey
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/usewormhol/random"
+	"golang.org/x/time/rate"
+)
+
+// Operation is one of the object package calls a Policy can allow or deny.
+type Operation string
+
+const (
+	OpStore    Operation = "store"
+	OpRetrieve Operation = "retrieve"
+	OpList     Operation = "list"
+	OpDelete   Operation = "delete"
+)
+
+const (
+	idBase       = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	idLength     = 16
+	secretLength = 40
+)
+
+var (
+	idGenerator     = random.NewStringGenerator(idBase)
+	secretGenerator = random.NewStringGenerator(idBase)
+
+	errAccessKeyInvalid  = errors.New("access key invalid")
+	errAccessKeyExpired  = errors.New("access key expired")
+	errOperationDenied   = errors.New("access key does not allow this operation")
+	errKeyPrefixDenied   = errors.New("access key does not allow this key prefix")
+	errSizeQuotaExceeded = errors.New("access key size quota exceeded")
+	errRateLimited       = errors.New("access key rate limit exceeded")
+)
+
+// Policy is the set of restrictions an AccessKey carries.
+type Policy struct {
+	TenantID   string
+	AllowedOps map[Operation]bool
+	KeyPrefix  string
+	SizeQuota  int64
+	ExpiresAt  time.Time
+	RateLimit  float64 // requests per second; 0 means unlimited
+}
+
+// AccessKey is a minted tenant-scoped credential pair plus the Policy it's
+// bound to.
+type AccessKey struct {
+	ID     string
+	Secret string
+	Policy Policy
+}
+
+// rateLimiters holds one rate.Limiter per access-key ID, shared across every
+// *AccessKey instance for that ID. KeyStore implementations like
+// RedisKeyStore hand back a freshly unmarshaled *AccessKey on every Get, so
+// the limiter can't live on the struct itself without resetting on every
+// lookup; keying it here by ID keeps it process-wide and consistent
+// regardless of which KeyStore is in use.
+var rateLimiters sync.Map // map[string]*rate.Limiter
+
+func (ak *AccessKey) rateLimiter() *rate.Limiter {
+	if limiter, ok := rateLimiters.Load(ak.ID); ok {
+		return limiter.(*rate.Limiter)
+	}
+
+	limiter, _ := rateLimiters.LoadOrStore(ak.ID, rate.NewLimiter(rate.Limit(ak.Policy.RateLimit), int(ak.Policy.RateLimit)+1))
+	return limiter.(*rate.Limiter)
+}
+
+// Mint generates a new AccessKey under policy and persists it to store.
+func Mint(store KeyStore, policy Policy) (*AccessKey, error) {
+	ak := &AccessKey{
+		ID:     idGenerator.Generate(idLength),
+		Secret: secretGenerator.Generate(secretLength),
+		Policy: policy,
+	}
+
+	if err := store.Put(ak); err != nil {
+		return nil, err
+	}
+
+	return ak, nil
+}
+
+// Authenticate looks up id in store and checks secret against it in
+// constant time.
+func Authenticate(store KeyStore, id string, secret string) (*AccessKey, error) {
+	ak, err := store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if ak == nil || subtle.ConstantTimeCompare([]byte(ak.Secret), []byte(secret)) != 1 {
+		return nil, errAccessKeyInvalid
+	}
+
+	return ak, nil
+}
+
+// Allow checks op against ak's Policy (operation, key prefix, size quota,
+// expiry) and consumes one unit of its rate limit, returning an error if any
+// of those reject the request.
+func (ak *AccessKey) Allow(op Operation, key string, size int64) error {
+	if !ak.Policy.ExpiresAt.IsZero() && time.Now().After(ak.Policy.ExpiresAt) {
+		return errAccessKeyExpired
+	}
+
+	if !ak.Policy.AllowedOps[op] {
+		return errOperationDenied
+	}
+
+	if ak.Policy.KeyPrefix != "" && key != "" && !strings.HasPrefix(key, ak.Policy.KeyPrefix) {
+		return errKeyPrefixDenied
+	}
+
+	if ak.Policy.SizeQuota > 0 && size > ak.Policy.SizeQuota {
+		return errSizeQuotaExceeded
+	}
+
+	if ak.Policy.RateLimit > 0 && !ak.rateLimiter().Allow() {
+		return errRateLimited
+	}
+
+	return nil
+}
+
+// KeyStore persists AccessKeys. A KeyStore implementation fetched via Get
+// should return (nil, nil) for an unknown id, not an error.
+type KeyStore interface {
+	Put(ak *AccessKey) error
+	Get(id string) (*AccessKey, error)
+	Delete(id string) error
+}
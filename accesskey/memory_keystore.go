@@ -0,0 +1,37 @@
+package accesskey
+
+import "sync"
+
+// MemoryKeyStore is a KeyStore backed by a process-local map, useful for
+// tests and single-instance deployments.
+type MemoryKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*AccessKey
+}
+
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: map[string]*AccessKey{}}
+}
+
+func (s *MemoryKeyStore) Put(ak *AccessKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[ak.ID] = ak
+	return nil
+}
+
+func (s *MemoryKeyStore) Get(id string) (*AccessKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keys[id], nil
+}
+
+func (s *MemoryKeyStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, id)
+	return nil
+}
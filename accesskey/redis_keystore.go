@@ -0,0 +1,50 @@
+package accesskey
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisKeyStore is a KeyStore backed by Redis, so access keys survive
+// restarts and are shared across instances. A DynamoDB-backed KeyStore
+// would implement the same three methods against a table keyed by id.
+type RedisKeyStore struct {
+	client *redis.Client
+	prefix string
+}
+
+func NewRedisKeyStore(client *redis.Client, keyPrefix string) *RedisKeyStore {
+	return &RedisKeyStore{client: client, prefix: keyPrefix}
+}
+
+func (s *RedisKeyStore) Put(ak *AccessKey) error {
+	data, err := json.Marshal(ak)
+	if err != nil {
+		return err
+	}
+
+	return s.client.Set(context.Background(), s.prefix+ak.ID, data, 0).Err()
+}
+
+func (s *RedisKeyStore) Get(id string) (*AccessKey, error) {
+	data, err := s.client.Get(context.Background(), s.prefix+id).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ak := &AccessKey{}
+	if err := json.Unmarshal(data, ak); err != nil {
+		return nil, err
+	}
+
+	return ak, nil
+}
+
+func (s *RedisKeyStore) Delete(id string) error {
+	return s.client.Del(context.Background(), s.prefix+id).Err()
+}
@@ -0,0 +1,97 @@
+package object
+
+import (
+	"errors"
+
+	"github.com/usewormhol/object/accesskey"
+)
+
+// tenantIDMetadataKey is the user-metadata key StoreAs stamps onto objects
+// it creates, so ListAs/RetrieveAs/DeleteAs can tell who owns a key without
+// needing their own storage.
+const tenantIDMetadataKey = "tenant-id"
+
+var errAccessKeyTenantMismatch = errors.New("object not owned by this access key's tenant")
+
+// StoreAs is Store scoped to ak's policy: it checks ak allows OpStore for
+// size, then stamps ak's tenant id into the object's metadata.
+func StoreAs(ak *accesskey.AccessKey, name string, size int64) (*Object, error) {
+	if err := ak.Allow(accesskey.OpStore, "", size); err != nil {
+		return nil, err
+	}
+
+	return store(name, size, map[string]string{tenantIDMetadataKey: ak.Policy.TenantID})
+}
+
+// RetrieveAs is Retrieve scoped to ak's policy: it checks ak allows
+// OpRetrieve for key, then requires the object's stamped tenant id match
+// ak's.
+func RetrieveAs(ak *accesskey.AccessKey, key string) (*Object, error) {
+	if err := ak.Allow(accesskey.OpRetrieve, key, 0); err != nil {
+		return nil, err
+	}
+
+	return retrieve(key, ak.Policy.TenantID)
+}
+
+// ListAs is List scoped to ak's policy: it checks ak allows OpList, then
+// filters the bucket listing down to objects stamped with ak's tenant id.
+func ListAs(ak *accesskey.AccessKey) ([]*Object, error) {
+	if err := ak.Allow(accesskey.OpList, "", 0); err != nil {
+		return nil, err
+	}
+
+	objects, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	owned := make([]*Object, 0, len(objects))
+	for _, obj := range objects {
+		var head *HeadInfo
+		err := instrumentOp("head", func() error {
+			var e error
+			head, e = getBackend().Head(obj.Key)
+			return e
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if head.Metadata[tenantIDMetadataKey] == ak.Policy.TenantID {
+			owned = append(owned, obj)
+		}
+	}
+
+	return owned, nil
+}
+
+// DeleteAs is Delete scoped to ak's policy: it checks ak allows OpDelete for
+// key, confirms the object is stamped with ak's tenant id, and deletes it
+// through Delete (trashing it unless WORMHOL_UNSAFE_DELETE is set).
+func DeleteAs(ak *accesskey.AccessKey, key string) error {
+	err := objectValidate(&key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := ak.Allow(accesskey.OpDelete, key, 0); err != nil {
+		return err
+	}
+
+	var head *HeadInfo
+	err = instrumentOp("head", func() error {
+		var e error
+		head, e = getBackend().Head(key)
+		return e
+	})
+	if err != nil {
+		return err
+	}
+
+	if head.Metadata[tenantIDMetadataKey] != ak.Policy.TenantID {
+		return errAccessKeyTenantMismatch
+	}
+
+	return (&Object{Key: key}).Delete()
+}
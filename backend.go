@@ -0,0 +1,90 @@
+package object
+
+import (
+	"sync"
+	"time"
+)
+
+// HeadInfo is the subset of object metadata a Backend must report from a
+// Head call, independent of the underlying storage provider.
+type HeadInfo struct {
+	ContentDisposition string
+	ContentLength      int64
+	LastModified       time.Time
+	Metadata           map[string]string
+}
+
+// ListEntry is a single object returned from a Backend List call.
+type ListEntry struct {
+	Key          string
+	LastModified time.Time
+}
+
+// CompletedPart is one part of a finished multipart upload, as reported by
+// the client after it PUTs the part to its presigned URL.
+type CompletedPart struct {
+	PartNumber int64
+	ETag       string
+}
+
+// MultipartUploadEntry is an in-progress multipart upload returned from a
+// Backend ListMultipartUploads call.
+type MultipartUploadEntry struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+// Backend abstracts the storage operations object.go needs, so alternate
+// providers (filesystem, in-memory, GCS, Azure) can be plugged in without
+// touching the top-level API. The default Backend is an S3-compatible
+// implementation; see s3backend.go.
+type Backend interface {
+	Put(key string, size int64, contentDisposition string, metadata map[string]string) (presignedUrl string, headers map[string]string, err error)
+	Get(key string, expiresIn time.Duration) (presignedUrl string, err error)
+	Head(key string) (*HeadInfo, error)
+	// List returns up to maxKeys entries matching prefix/delimiter, starting
+	// after continuationToken (pass "" to start from the beginning). When
+	// nextContinuationToken is non-empty, call List again with it to fetch
+	// the next page; an empty entries page with a non-empty
+	// nextContinuationToken is valid (e.g. a page that was entirely rolled
+	// up into delimiter-collapsed common prefixes) and callers must keep
+	// paging on the token, not on the last entry seen.
+	List(prefix string, delimiter string, maxKeys int64, continuationToken string) (entries []ListEntry, nextContinuationToken string, err error)
+	Copy(srcKey string, dstKey string, metadata map[string]string) error
+	Delete(key string) error
+
+	CreateMultipartUpload(key string, contentDisposition string) (uploadId string, err error)
+	PresignUploadPart(key string, uploadId string, partNumber int64) (presignedUrl string, err error)
+	PresignCompleteMultipartUpload(key string, uploadId string) (presignedUrl string, err error)
+	PresignAbortMultipartUpload(key string, uploadId string) (presignedUrl string, err error)
+	CompleteMultipartUpload(key string, uploadId string, parts []CompletedPart) error
+	ListMultipartUploads() ([]MultipartUploadEntry, error)
+	AbortMultipartUpload(key string, uploadId string) error
+}
+
+var (
+	backend     Backend
+	backendOnce sync.Once
+)
+
+// SetBackend overrides the default S3 Backend, primarily so tests can inject
+// a fake or in-memory implementation without touching environment state. It
+// must be called before any of Store/Retrieve/List/Delete/Reap run, not
+// concurrently with them.
+func SetBackend(b Backend) {
+	backend = b
+}
+
+// getBackend returns the configured Backend, lazily constructing the default
+// S3 implementation (and its underlying client) on first use. backendOnce
+// guards that lazy construction so concurrent callers racing to be the first
+// request don't race on the backend variable itself.
+func getBackend() Backend {
+	backendOnce.Do(func() {
+		if backend == nil {
+			backend = newS3Backend()
+		}
+	})
+	return backend
+}
@@ -0,0 +1,49 @@
+package object
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/usewormhol/object/metrics"
+)
+
+var metricsCollector = metrics.New(nil)
+
+// SetMetricsRegisterer swaps the Prometheus registry the package's metrics
+// are published under, e.g. to share a process's default registry instead
+// of the private one used by default.
+func SetMetricsRegisterer(registry *prometheus.Registry) {
+	metricsCollector = metrics.New(registry)
+}
+
+// MetricsHandler returns an http.Handler serving this package's Prometheus
+// metrics in the exposition format.
+func MetricsHandler() http.Handler {
+	return metricsCollector.Handler()
+}
+
+// instrumentOp runs fn, recording its latency and, on failure, the AWS error
+// code under the given op label ("put", "get", "head", "list", "delete",
+// "copy", or one of the multipart labels "create_multipart_upload",
+// "upload_part", "complete_multipart_upload", "abort_multipart_upload" and
+// "list_multipart_uploads").
+func instrumentOp(op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	metricsCollector.ObserveOp(op, time.Since(start))
+
+	if err != nil {
+		code := "unknown"
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) {
+			code = awsErr.Code()
+		}
+		metricsCollector.ObserveOpError(op, code)
+	}
+
+	return err
+}
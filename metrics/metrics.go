@@ -0,0 +1,148 @@
+// Package metrics provides Prometheus instrumentation for the object
+// package's S3 operations, mirroring the metrics Arvados added to its
+// keepstore S3 volume driver.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Collector holds the Prometheus metrics published by the object package.
+type Collector struct {
+	registry *prometheus.Registry
+
+	OpTotal                    *prometheus.CounterVec
+	OpDuration                 *prometheus.HistogramVec
+	OpErrorsTotal              *prometheus.CounterVec
+	KeyGenerationRetries       prometheus.Histogram
+	KeyGenerationDuration      prometheus.Histogram
+	ReapLastDeletedTotal       prometheus.Gauge
+	ReapLastDurationSeconds    prometheus.Gauge
+	CloudflarePurgeErrorsTotal prometheus.Counter
+}
+
+// New builds a Collector and registers its metrics with registry. A nil
+// registry gets a fresh, private *prometheus.Registry so multiple Collectors
+// (e.g. in tests) never collide; pass prometheus.DefaultRegisterer's
+// registry to publish alongside the rest of a process's metrics.
+func New(registry *prometheus.Registry) *Collector {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+	}
+
+	c := &Collector{
+		registry: registry,
+
+		OpTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "s3_operations_total",
+			Help:      "Total number of S3 operations performed, labeled by op (put, get, head, list, delete, copy, and the multipart upload ops).",
+		}, []string{"op"}),
+
+		OpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "s3_operation_duration_seconds",
+			Help:      "Latency of S3 operations, labeled by op.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"op"}),
+
+		OpErrorsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "s3_operation_errors_total",
+			Help:      "Total number of S3 operation errors, labeled by op and AWS error code.",
+		}, []string{"op", "code"}),
+
+		KeyGenerationRetries: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "key_generation_retries",
+			Help:      "Number of HeadObject retries objectGenerateUniqueKey needed to find a free key.",
+			Buckets:   prometheus.LinearBuckets(0, 1, 10),
+		}),
+
+		KeyGenerationDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "key_generation_duration_seconds",
+			Help:      "Time objectGenerateUniqueKey took to find a free key.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+
+		ReapLastDeletedTotal: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "reap_last_deleted_total",
+			Help:      "Number of objects trashed or hard-deleted, plus multipart uploads aborted, by the most recent Reap run.",
+		}),
+
+		ReapLastDurationSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "reap_last_duration_seconds",
+			Help:      "Duration of the most recent Reap run.",
+		}),
+
+		CloudflarePurgeErrorsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "wormhol",
+			Subsystem: "object",
+			Name:      "cloudflare_purge_errors_total",
+			Help:      "Total number of Cloudflare cache purge failures.",
+		}),
+	}
+
+	registry.MustRegister(
+		c.OpTotal,
+		c.OpDuration,
+		c.OpErrorsTotal,
+		c.KeyGenerationRetries,
+		c.KeyGenerationDuration,
+		c.ReapLastDeletedTotal,
+		c.ReapLastDurationSeconds,
+		c.CloudflarePurgeErrorsTotal,
+	)
+
+	return c
+}
+
+// ObserveOp records a completed S3 operation's op label and latency.
+func (c *Collector) ObserveOp(op string, duration time.Duration) {
+	c.OpTotal.WithLabelValues(op).Inc()
+	c.OpDuration.WithLabelValues(op).Observe(duration.Seconds())
+}
+
+// ObserveOpError records an S3 operation that failed with the given AWS
+// error code ("unknown" if the error isn't an awserr.Error).
+func (c *Collector) ObserveOpError(op string, code string) {
+	c.OpErrorsTotal.WithLabelValues(op, code).Inc()
+}
+
+// ObserveKeyGeneration records how many HeadObject retries and how long
+// objectGenerateUniqueKey needed to find a free key.
+func (c *Collector) ObserveKeyGeneration(retries int, duration time.Duration) {
+	c.KeyGenerationRetries.Observe(float64(retries))
+	c.KeyGenerationDuration.Observe(duration.Seconds())
+}
+
+// SetReapResult records the outcome of the most recently completed Reap run.
+func (c *Collector) SetReapResult(deleted int, duration time.Duration) {
+	c.ReapLastDeletedTotal.Set(float64(deleted))
+	c.ReapLastDurationSeconds.Set(duration.Seconds())
+}
+
+// IncCloudflarePurgeErrors records a failed Cloudflare cache purge.
+func (c *Collector) IncCloudflarePurgeErrors() {
+	c.CloudflarePurgeErrorsTotal.Inc()
+}
+
+// Handler returns an http.Handler serving this Collector's metrics in the
+// Prometheus exposition format.
+func (c *Collector) Handler() http.Handler {
+	return promhttp.HandlerFor(c.registry, promhttp.HandlerOpts{})
+}
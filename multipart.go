@@ -0,0 +1,167 @@
+package object
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/usewormhol/env"
+)
+
+var (
+	MULTIPART_PART_SIZE_MIN = env.Int64("WORMHOL_MULTIPART_PART_SIZE_MIN_BYTES", 5*1024*1024, env.Optional)
+	MULTIPART_MAX_PARTS     = env.Int64("WORMHOL_MULTIPART_MAX_PARTS", 10000, env.Optional)
+
+	errMultipartPartSizeInvalid = errors.New("multipart part size invalid")
+)
+
+// MultipartPart is one presigned UploadPart URL a client should PUT a chunk
+// of the upload to.
+type MultipartPart struct {
+	PartNumber      int64  `json:"part_number"`
+	PresignedPutUrl string `json:"presigned_put_url"`
+}
+
+// MultipartUpload is the result of StoreMultipart: everything a client needs
+// to drive an S3 multipart upload without further round trips to this
+// service.
+type MultipartUpload struct {
+	Key                  string          `json:"key"`
+	UploadId             string          `json:"upload_id"`
+	Parts                []MultipartPart `json:"parts"`
+	PresignedCompleteUrl string          `json:"presigned_complete_url"`
+	PresignedAbortUrl    string          `json:"presigned_abort_url"`
+}
+
+// StoreMultipart is Store's counterpart for uploads too large (or too slow)
+// for a single presigned PUT. It opens an S3 multipart upload and returns
+// one presigned UploadPart URL per part plus presigned Complete/Abort URLs.
+func StoreMultipart(name string, size int64, partSize int64) (*MultipartUpload, error) {
+	err := objectValidate(nil, &name, &size)
+	if err != nil {
+		return nil, err
+	}
+	if err := multipartValidatePartSize(size, partSize); err != nil {
+		return nil, err
+	}
+
+	key, err := objectGenerateUniqueKey(&OBJECT_KEY_DELAY_MAX)
+	if err != nil {
+		return nil, err
+	}
+	contentDisposition := fmt.Sprintf(`attachment; filename="%s"`, name)
+
+	var uploadId string
+	err = instrumentOp("create_multipart_upload", func() error {
+		var e error
+		uploadId, e = getBackend().CreateMultipartUpload(key, contentDisposition)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	numParts := (size + partSize - 1) / partSize
+	parts := make([]MultipartPart, 0, numParts)
+	for partNumber := int64(1); partNumber <= numParts; partNumber++ {
+		var partUrl string
+		err = instrumentOp("upload_part", func() error {
+			var e error
+			partUrl, e = getBackend().PresignUploadPart(key, uploadId, partNumber)
+			return e
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, MultipartPart{PartNumber: partNumber, PresignedPutUrl: partUrl})
+	}
+
+	var completeUrl string
+	err = instrumentOp("complete_multipart_upload", func() error {
+		var e error
+		completeUrl, e = getBackend().PresignCompleteMultipartUpload(key, uploadId)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var abortUrl string
+	err = instrumentOp("abort_multipart_upload", func() error {
+		var e error
+		abortUrl, e = getBackend().PresignAbortMultipartUpload(key, uploadId)
+		return e
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultipartUpload{
+		Key:                  key,
+		UploadId:             uploadId,
+		Parts:                parts,
+		PresignedCompleteUrl: completeUrl,
+		PresignedAbortUrl:    abortUrl,
+	}, nil
+}
+
+// Complete finishes a multipart upload server-side, for clients that would
+// rather POST their parts' ETags back to us than call S3's presigned
+// Complete URL directly.
+func Complete(key string, uploadId string, parts []CompletedPart) error {
+	err := objectValidate(&key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return instrumentOp("complete_multipart_upload", func() error {
+		return getBackend().CompleteMultipartUpload(key, uploadId, parts)
+	})
+}
+
+func multipartValidatePartSize(size int64, partSize int64) error {
+	if partSize < MULTIPART_PART_SIZE_MIN {
+		return errMultipartPartSizeInvalid
+	}
+
+	numParts := (size + partSize - 1) / partSize
+	if numParts > MULTIPART_MAX_PARTS {
+		return errMultipartPartSizeInvalid
+	}
+
+	return nil
+}
+
+// sweepMultipartUploads aborts in-progress multipart uploads older than
+// OBJECT_TIME_TO_LIVE, so abandoned uploads don't linger as storage cost.
+func sweepMultipartUploads() (int, error) {
+	n := 0
+
+	var uploads []MultipartUploadEntry
+	err := instrumentOp("list_multipart_uploads", func() error {
+		var e error
+		uploads, e = getBackend().ListMultipartUploads()
+		return e
+	})
+	if err != nil {
+		return n, err
+	}
+
+	now := time.Now()
+	for _, upload := range uploads {
+		if !upload.Initiated.Add(OBJECT_TIME_TO_LIVE).Before(now) {
+			continue
+		}
+
+		err := instrumentOp("abort_multipart_upload", func() error {
+			return getBackend().AbortMultipartUpload(upload.Key, upload.UploadId)
+		})
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -1,6 +1,7 @@
 package object
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/url"
@@ -10,10 +11,7 @@ import (
 	"github.com/usewormhol/env"
 	"github.com/usewormhol/random"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/cloudflare/cloudflare-go"
 )
@@ -30,10 +28,10 @@ type Object struct {
 }
 
 var (
-	S3_REGION                   = env.String("WORMHOL_S3_REGION", "", env.Required)
-	S3_ACCESS_KEY_ID            = env.String("WORMHOL_S3_ACCESS_KEY_ID", "", env.Required)
-	S3_SECRET_ACCESS_KEY        = env.String("WORMHOL_S3_SECRET_ACCESS_KEY", "", env.Required)
-	S3_BUCKET                   = env.String("WORMHOL_S3_BUCKET", "", env.Required)
+	S3_REGION                   = env.String("WORMHOL_S3_REGION", "us-east-1", env.Optional)
+	S3_ACCESS_KEY_ID            = env.String("WORMHOL_S3_ACCESS_KEY_ID", "", env.Optional)
+	S3_SECRET_ACCESS_KEY        = env.String("WORMHOL_S3_SECRET_ACCESS_KEY", "", env.Optional)
+	S3_BUCKET                   = env.String("WORMHOL_S3_BUCKET", "wormhol", env.Optional)
 	S3_ACL                      = env.String("WORMHOL_S3_ACL", s3.ObjectCannedACLPrivate, env.Optional)
 	S3_SSE                      = env.String("WORMHOL_S3_SSE", s3.ServerSideEncryptionAes256, env.Optional)
 	S3_STORAGE_CLASS            = env.String("WORMHOL_S3_STORAGE_CLASS", s3.ObjectStorageClassOnezoneIa, env.Optional)
@@ -50,25 +48,25 @@ var (
 	OBJECT_NAME_LENGTH_MIN      = env.Int("WORMHOL_OBJECT_NAME_LENGTH_MIN", 1, env.Optional)
 	OBJECT_NAME_LENGTH_MAX      = env.Int("WORMHOL_OBJECT_NAME_LENGTH_MAX", 255, env.Optional)
 	OBJECT_SIZE_MIN             = env.Int64("WORMHOL_OBJECT_SIZE_MIN_BYTES", 0, env.Optional)
-	OBJECT_SIZE_MAX             = env.Int64("WORMHOL_OBJECT_SIZE_MAX_BYTES", 5*1000000000, env.Optional)
+	OBJECT_SIZE_MAX             = env.Int64("WORMHOL_OBJECT_SIZE_MAX_BYTES", 5*1000000000*1000, env.Optional)
 	OBJECT_TIME_TO_LIVE         = time.Duration(env.Int("WORMHOL_OBJECT_TIME_TO_LIVE_SECONDS", 60*60*24*3-1, env.Optional)) * time.Second
+	S3_RACE_WINDOW              = time.Duration(env.Int("WORMHOL_S3_RACE_WINDOW_SECONDS", 60*60*24, env.Optional)) * time.Second
 
 	errObjectKeyInvalid                = errors.New("object key invalid")
 	errObjectNameInvalid               = errors.New("object name invalid")
 	errObjectSizeInvalid               = errors.New("object size invalid")
 	errObjectKeyGenerationTookMaxDelay = errors.New("object key generation took max delay")
 
-	s3Client = s3.New(session.Must(session.NewSession(&aws.Config{
-		Region:      aws.String(S3_REGION),
-		Credentials: credentials.NewStaticCredentials(S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY, ""),
-	})), nil)
-
 	cloudflareClient *cloudflare.API
 
 	randomStringGenerator = random.NewStringGenerator(OBJECT_KEY_BASE)
 )
 
 func Store(name string, size int64) (*Object, error) {
+	return store(name, size, nil)
+}
+
+func store(name string, size int64, metadata map[string]string) (*Object, error) {
 	err := objectValidate(nil, &name, &size)
 	if err != nil {
 		return nil, err
@@ -80,55 +78,61 @@ func Store(name string, size int64) (*Object, error) {
 	}
 	contentDisposition := fmt.Sprintf(`attachment; filename="%s"`, name)
 
-	req, _ := s3Client.PutObjectRequest(&s3.PutObjectInput{
-		ACL:                  aws.String(S3_ACL),
-		Bucket:               aws.String(S3_BUCKET),
-		ContentDisposition:   aws.String(contentDisposition),
-		ContentLength:        aws.Int64(size),
-		Key:                  aws.String(key),
-		ServerSideEncryption: aws.String(S3_SSE),
-		StorageClass:         aws.String(S3_STORAGE_CLASS),
+	var url string
+	var headers map[string]string
+	err = instrumentOp("put", func() error {
+		var e error
+		url, headers, e = getBackend().Put(key, size, contentDisposition, metadata)
+		return e
 	})
-
-	url, err := req.Presign(time.Hour * 24)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Object{
-		Key:             key,
-		PresignedPutUrl: url,
-		PresignedPutHeaders: map[string]string{
-			"Content-Disposition":          contentDisposition,
-			"X-AMZ-Acl":                    S3_ACL,
-			"X-AMZ-Server-Side-Encryption": S3_SSE,
-			"X-AMZ-Storage-Class":          S3_STORAGE_CLASS,
-		},
+		Key:                 key,
+		PresignedPutUrl:     url,
+		PresignedPutHeaders: headers,
 	}, nil
 }
 
 func Retrieve(key string) (*Object, error) {
+	return retrieve(key, "")
+}
+
+// retrieve is Retrieve's tenant-aware counterpart: if requireTenantID is
+// non-empty, the object's stamped tenant-id metadata must match it.
+func retrieve(key string, requireTenantID string) (*Object, error) {
 	err := objectValidate(&key, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	object, err := s3Client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(S3_BUCKET), Key: aws.String(key)})
+	var object *HeadInfo
+	err = instrumentOp("head", func() error {
+		var e error
+		object, e = getBackend().Head(key)
+		return e
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	name, err := url.QueryUnescape(strings.Split(*object.ContentDisposition, `"`)[1])
+	if requireTenantID != "" && object.Metadata[tenantIDMetadataKey] != requireTenantID {
+		return nil, errAccessKeyTenantMismatch
+	}
+
+	name, err := url.QueryUnescape(strings.Split(object.ContentDisposition, `"`)[1])
 	if err != nil {
 		return nil, err
 	}
 
-	req, _ := s3Client.GetObjectRequest(&s3.GetObjectInput{
-		Bucket: aws.String(S3_BUCKET),
-		Key:    aws.String(key),
+	var getUrl string
+	err = instrumentOp("get", func() error {
+		var e error
+		getUrl, e = getBackend().Get(key, object.LastModified.Add(OBJECT_TIME_TO_LIVE).Sub(time.Now()))
+		return e
 	})
-
-	url, err := req.Presign(object.LastModified.Add(OBJECT_TIME_TO_LIVE).Sub(time.Now()))
 	if err != nil {
 		return nil, err
 	}
@@ -136,68 +140,44 @@ func Retrieve(key string) (*Object, error) {
 	return &Object{
 		Key:               key,
 		Name:              name,
-		SizeBytes:         *object.ContentLength,
+		SizeBytes:         object.ContentLength,
 		ExpirationSeconds: object.LastModified.Add(OBJECT_TIME_TO_LIVE).UTC().Unix(),
-		PresignedGetUrl:   url,
+		PresignedGetUrl:   getUrl,
 	}, nil
 }
 
 func List() ([]*Object, error) {
 	var objects []*Object
 
-	out, err := s3Client.ListObjectsV2(&s3.ListObjectsV2Input{
-		Bucket:  aws.String(S3_BUCKET),
-		MaxKeys: aws.Int64(S3_LIST_OBJECTS_MAX_KEYS),
-	})
-	if err != nil {
-		return nil, err
-	}
-
-	first := true
-	for first || *out.IsTruncated {
-		if !first {
-			out, err = s3Client.ListObjectsV2(&s3.ListObjectsV2Input{
-				Bucket:     aws.String(S3_BUCKET),
-				MaxKeys:    aws.Int64(S3_LIST_OBJECTS_MAX_KEYS),
-				StartAfter: aws.String(objects[len(objects)-1].Key),
-			})
-			if err != nil {
-				return nil, err
-			}
+	token := ""
+	for {
+		var entries []ListEntry
+		var nextToken string
+		err := instrumentOp("list", func() error {
+			var e error
+			entries, nextToken, e = getBackend().List("", "/", S3_LIST_OBJECTS_MAX_KEYS, token)
+			return e
+		})
+		if err != nil {
+			return nil, err
 		}
 
-		for _, obj := range out.Contents {
+		for _, entry := range entries {
 			objects = append(objects, &Object{
-				Key:          *obj.Key,
-				LastModified: *obj.LastModified,
+				Key:          entry.Key,
+				LastModified: entry.LastModified,
 			})
 		}
 
-		first = false
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
 	}
 
 	return objects, nil
 }
 
-func (o *Object) Delete() error {
-	var err error
-
-	_, err = s3Client.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(S3_BUCKET),
-		Key:    aws.String(o.Key),
-	})
-	if err != nil {
-		return err
-	}
-
-	err = o.purgeCache()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func (o *Object) purgeCache() error {
 	if CLOUDFLARE_ZONE != "" {
 		var err error
@@ -215,13 +195,14 @@ func (o *Object) purgeCache() error {
 			return err
 		}
 
-		_, err = cloudflareClient.PurgeCache(CLOUDFLARE_ZONE, cloudflare.PurgeCacheRequest{
+		_, err = cloudflareClient.PurgeCache(context.Background(), CLOUDFLARE_ZONE, cloudflare.PurgeCacheRequest{
 			Files: []string{
 				fmt.Sprintf("%s/%s", CLOUDFLARE_HOST, o.Key),
 				fmt.Sprintf("%s/%s/", CLOUDFLARE_HOST, o.Key),
 			},
 		})
 		if err != nil {
+			metricsCollector.IncCloudflarePurgeErrors()
 			return err
 		}
 	}
@@ -230,6 +211,7 @@ func (o *Object) purgeCache() error {
 }
 
 func Reap() (int, error) {
+	t_start := time.Now()
 	n := 0
 
 	objects, err := List()
@@ -239,6 +221,13 @@ func Reap() (int, error) {
 
 	now := time.Now()
 	for _, obj := range objects {
+		if now.Sub(obj.LastModified) < S3_RACE_WINDOW {
+			// LastModified may lag behind reality on eventually consistent
+			// backends; skip anything that recent rather than risk
+			// reaping an object that was just uploaded.
+			continue
+		}
+
 		if obj.LastModified.Add(OBJECT_TIME_TO_LIVE).Before(now) {
 			if err := obj.Delete(); err != nil {
 				return n, err
@@ -247,6 +236,20 @@ func Reap() (int, error) {
 		}
 	}
 
+	emptied, err := EmptyTrash()
+	if err != nil {
+		return n, err
+	}
+	n += emptied
+
+	aborted, err := sweepMultipartUploads()
+	if err != nil {
+		return n, err
+	}
+	n += aborted
+
+	metricsCollector.SetReapResult(n, time.Since(t_start))
+
 	return n, nil
 }
 
@@ -281,18 +284,31 @@ func objectValidate(key *string, name *string, size *int64) error {
 
 func objectGenerateUniqueKey(maxDelay *time.Duration) (string, error) {
 	t_start := time.Now()
+	retries := 0
 
-	key := randomStringGenerator.Generate(OBJECT_KEY_LENGTH)
-	headObjectInput := &s3.HeadObjectInput{Bucket: aws.String(S3_BUCKET), Key: &key}
-	_, err := s3Client.HeadObject(headObjectInput)
+	for {
+		key := randomStringGenerator.Generate(OBJECT_KEY_LENGTH)
+
+		if !recentlyIssuedKeys.contains(key) {
+			err := instrumentOp("head", func() error {
+				_, e := getBackend().Head(key)
+				return e
+			})
+
+			var awsErr awserr.Error
+			if err != nil && errors.As(err, &awsErr) && awsErr.Code() == "NotFound" {
+				recentlyIssuedKeys.add(key)
+				metricsCollector.ObserveKeyGeneration(retries, time.Since(t_start))
+				return key, nil
+			}
+			// A nil error means HeadObject found the key, i.e. it's
+			// already taken; any other error means we couldn't confirm
+			// the key is free. Either way, fall through and retry.
+			retries++
+		}
 
-	for err.(awserr.Error).Code() != "NotFound" {
 		if time.Since(t_start) > *maxDelay {
 			return "", errObjectKeyGenerationTookMaxDelay
 		}
-		key = randomStringGenerator.Generate(OBJECT_KEY_LENGTH)
-		_, err = s3Client.HeadObject(headObjectInput)
 	}
-
-	return key, nil
 }
@@ -0,0 +1,288 @@
+package object
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+
+	"github.com/usewormhol/object/accesskey"
+)
+
+// fakeObject is one entry stored by fakeBackend.
+type fakeObject struct {
+	size               int64
+	contentDisposition string
+	lastModified       time.Time
+	metadata           map[string]string
+}
+
+// fakeBackend is an in-memory Backend used to exercise object.go without
+// talking to S3. Multipart methods are stubs: nothing in this file drives
+// multipart uploads yet.
+type fakeBackend struct {
+	mu          sync.Mutex
+	objects     map[string]*fakeObject
+	headErrOnce map[string]error // consumed (one-shot) by the next Head(key) call
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{objects: map[string]*fakeObject{}, headErrOnce: map[string]error{}}
+}
+
+func (b *fakeBackend) Put(key string, size int64, contentDisposition string, metadata map[string]string) (string, map[string]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.objects[key] = &fakeObject{
+		size:               size,
+		contentDisposition: contentDisposition,
+		lastModified:       time.Now(),
+		metadata:           metadata,
+	}
+	return "https://fake.test/" + key, map[string]string{"Content-Disposition": contentDisposition}, nil
+}
+
+func (b *fakeBackend) Get(key string, expiresIn time.Duration) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.objects[key]; !ok {
+		return "", awserr.New("NotFound", "not found", nil)
+	}
+	return "https://fake.test/" + key + "?get", nil
+}
+
+func (b *fakeBackend) Head(key string) (*HeadInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err, ok := b.headErrOnce[key]; ok {
+		delete(b.headErrOnce, key)
+		return nil, err
+	}
+
+	obj, ok := b.objects[key]
+	if !ok {
+		return nil, awserr.New("NotFound", "not found", nil)
+	}
+	return &HeadInfo{
+		ContentDisposition: obj.contentDisposition,
+		ContentLength:      obj.size,
+		LastModified:       obj.lastModified,
+		Metadata:           obj.metadata,
+	}, nil
+}
+
+func (b *fakeBackend) List(prefix string, delimiter string, maxKeys int64, continuationToken string) ([]ListEntry, string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keys := make([]string, 0, len(b.objects))
+	for key := range b.objects {
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if delimiter != "" && strings.Contains(rest, delimiter) {
+			continue // collapsed into a common prefix, excluded like real S3
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]ListEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, ListEntry{Key: key, LastModified: b.objects[key].lastModified})
+	}
+	return entries, "", nil
+}
+
+func (b *fakeBackend) Copy(srcKey string, dstKey string, metadata map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, ok := b.objects[srcKey]
+	if !ok {
+		return awserr.New("NoSuchKey", "not found", nil)
+	}
+
+	dst := &fakeObject{size: src.size, contentDisposition: src.contentDisposition, lastModified: time.Now(), metadata: src.metadata}
+	if metadata != nil {
+		dst.metadata = metadata
+	}
+	b.objects[dstKey] = dst
+	return nil
+}
+
+func (b *fakeBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *fakeBackend) CreateMultipartUpload(key string, contentDisposition string) (string, error) {
+	return "fake-upload-id", nil
+}
+
+func (b *fakeBackend) PresignUploadPart(key string, uploadId string, partNumber int64) (string, error) {
+	return "", nil
+}
+
+func (b *fakeBackend) PresignCompleteMultipartUpload(key string, uploadId string) (string, error) {
+	return "", nil
+}
+
+func (b *fakeBackend) PresignAbortMultipartUpload(key string, uploadId string) (string, error) {
+	return "", nil
+}
+
+func (b *fakeBackend) CompleteMultipartUpload(key string, uploadId string, parts []CompletedPart) error {
+	return nil
+}
+
+func (b *fakeBackend) ListMultipartUploads() ([]MultipartUploadEntry, error) {
+	return nil, nil
+}
+
+func (b *fakeBackend) AbortMultipartUpload(key string, uploadId string) error {
+	return nil
+}
+
+func TestStoreRetrieve(t *testing.T) {
+	SetBackend(newFakeBackend())
+
+	stored, err := Store("hello.txt", 5)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	retrieved, err := Retrieve(stored.Key)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if retrieved.Name != "hello.txt" {
+		t.Errorf("Name = %q, want %q", retrieved.Name, "hello.txt")
+	}
+	if retrieved.SizeBytes != 5 {
+		t.Errorf("SizeBytes = %d, want 5", retrieved.SizeBytes)
+	}
+}
+
+func TestReapTrashesExpiredObjects(t *testing.T) {
+	SetBackend(newFakeBackend())
+
+	oldTTL, oldRaceWindow := OBJECT_TIME_TO_LIVE, S3_RACE_WINDOW
+	OBJECT_TIME_TO_LIVE, S3_RACE_WINDOW = time.Millisecond, 0
+	defer func() { OBJECT_TIME_TO_LIVE, S3_RACE_WINDOW = oldTTL, oldRaceWindow }()
+
+	stored, err := Store("old.txt", 5)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	n, err := Reap()
+	if err != nil {
+		t.Fatalf("Reap: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Reap deleted %d objects, want 1", n)
+	}
+
+	if _, err := Retrieve(stored.Key); err == nil {
+		t.Errorf("Retrieve succeeded for an object Reap should have trashed")
+	}
+}
+
+func TestUntrashRestoresWithinLifetime(t *testing.T) {
+	SetBackend(newFakeBackend())
+
+	stored, err := Store("keepme.txt", 5)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	if err := (&Object{Key: stored.Key}).Delete(); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := Retrieve(stored.Key); err == nil {
+		t.Fatalf("Retrieve succeeded on a trashed object before Untrash")
+	}
+
+	if err := Untrash(stored.Key); err != nil {
+		t.Fatalf("Untrash: %v", err)
+	}
+	if _, err := Retrieve(stored.Key); err != nil {
+		t.Fatalf("Retrieve after Untrash: %v", err)
+	}
+}
+
+// TestEmptyTrashSkipsOnTransientHeadError guards against EmptyTrash treating
+// a transient failure to confirm an object's trash lifetime the same as
+// having confirmed it elapsed.
+func TestEmptyTrashSkipsOnTransientHeadError(t *testing.T) {
+	backend := newFakeBackend()
+	SetBackend(backend)
+
+	oldLifetime := TRASH_LIFETIME
+	TRASH_LIFETIME = time.Hour
+	defer func() { TRASH_LIFETIME = oldLifetime }()
+
+	stored, err := Store("fresh.txt", 5)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := (&Object{Key: stored.Key}).Delete(); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	backend.headErrOnce[TRASH_PREFIX+stored.Key] = errors.New("transient throttling error")
+
+	if _, err := EmptyTrash(); err == nil {
+		t.Fatalf("EmptyTrash succeeded despite a transient Head error")
+	}
+
+	if err := Untrash(stored.Key); err != nil {
+		t.Errorf("Untrash after failed EmptyTrash: %v", err)
+	}
+}
+
+func TestStoreAsScopesAccessByTenant(t *testing.T) {
+	SetBackend(newFakeBackend())
+
+	keyStore := accesskey.NewMemoryKeyStore()
+	owner, err := accesskey.Mint(keyStore, accesskey.Policy{
+		TenantID:   "tenant-a",
+		AllowedOps: map[accesskey.Operation]bool{accesskey.OpStore: true, accesskey.OpRetrieve: true},
+	})
+	if err != nil {
+		t.Fatalf("Mint owner: %v", err)
+	}
+	other, err := accesskey.Mint(keyStore, accesskey.Policy{
+		TenantID:   "tenant-b",
+		AllowedOps: map[accesskey.Operation]bool{accesskey.OpRetrieve: true},
+	})
+	if err != nil {
+		t.Fatalf("Mint other: %v", err)
+	}
+
+	stored, err := StoreAs(owner, "mine.txt", 5)
+	if err != nil {
+		t.Fatalf("StoreAs: %v", err)
+	}
+
+	if _, err := RetrieveAs(owner, stored.Key); err != nil {
+		t.Fatalf("RetrieveAs(owner): %v", err)
+	}
+	if _, err := RetrieveAs(other, stored.Key); err == nil {
+		t.Errorf("RetrieveAs(other) succeeded across tenants")
+	}
+}
@@ -0,0 +1,43 @@
+package object
+
+import (
+	"sync"
+	"time"
+)
+
+// recentKeySet remembers keys this process has just issued from
+// objectGenerateUniqueKey, so a second call can avoid handing out the same
+// key again while it's still invisible to HeadObject on eventually
+// consistent S3-compatible backends. Entries expire after ttl.
+type recentKeySet struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	issued map[string]time.Time
+}
+
+var recentlyIssuedKeys = &recentKeySet{ttl: S3_RACE_WINDOW, issued: map[string]time.Time{}}
+
+func (s *recentKeySet) contains(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepLocked()
+	_, ok := s.issued[key]
+	return ok
+}
+
+func (s *recentKeySet) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.issued[key] = time.Now()
+}
+
+func (s *recentKeySet) sweepLocked() {
+	now := time.Now()
+	for key, issuedAt := range s.issued {
+		if now.Sub(issuedAt) > s.ttl {
+			delete(s.issued, key)
+		}
+	}
+}
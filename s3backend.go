@@ -0,0 +1,281 @@
+package object
+
+import (
+	"strings"
+	"time"
+
+	"github.com/usewormhol/env"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	S3_ENDPOINT                   = env.String("WORMHOL_S3_ENDPOINT", "", env.Optional)
+	S3_FORCE_PATH_STYLE           = env.Bool("WORMHOL_S3_FORCE_PATH_STYLE", false, env.Optional)
+	S3_SHARED_CREDENTIALS_FILE    = env.String("WORMHOL_S3_SHARED_CREDENTIALS_FILE", "", env.Optional)
+	S3_SHARED_CREDENTIALS_PROFILE = env.String("WORMHOL_S3_SHARED_CREDENTIALS_PROFILE", "default", env.Optional)
+)
+
+// s3Backend is the default Backend, talking to S3 or any S3-compatible
+// service reachable via WORMHOL_S3_ENDPOINT (MinIO, R2, SeaweedFS, ...).
+type s3Backend struct {
+	client *s3.S3
+}
+
+func newS3Backend() *s3Backend {
+	config := &aws.Config{
+		Region:      aws.String(S3_REGION),
+		Credentials: s3Credentials(),
+	}
+	if S3_ENDPOINT != "" {
+		config.Endpoint = aws.String(S3_ENDPOINT)
+	}
+	if S3_FORCE_PATH_STYLE {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	return &s3Backend{client: s3.New(session.Must(session.NewSession(config)))}
+}
+
+// s3Credentials picks a credentials provider by what's configured in the
+// environment: static keys, a shared credentials file, or (the default)
+// EC2/ECS instance metadata role credentials.
+func s3Credentials() *credentials.Credentials {
+	if S3_ACCESS_KEY_ID != "" && S3_SECRET_ACCESS_KEY != "" {
+		return credentials.NewStaticCredentials(S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY, "")
+	}
+
+	if S3_SHARED_CREDENTIALS_FILE != "" {
+		return credentials.NewSharedCredentials(S3_SHARED_CREDENTIALS_FILE, S3_SHARED_CREDENTIALS_PROFILE)
+	}
+
+	return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{
+		Client: ec2metadata.New(session.Must(session.NewSession())),
+	})
+}
+
+func (b *s3Backend) Put(key string, size int64, contentDisposition string, metadata map[string]string) (string, map[string]string, error) {
+	input := &s3.PutObjectInput{
+		ACL:                  aws.String(S3_ACL),
+		Bucket:               aws.String(S3_BUCKET),
+		ContentDisposition:   aws.String(contentDisposition),
+		ContentLength:        aws.Int64(size),
+		Key:                  aws.String(key),
+		ServerSideEncryption: aws.String(S3_SSE),
+		StorageClass:         aws.String(S3_STORAGE_CLASS),
+	}
+	if metadata != nil {
+		input.Metadata = aws.StringMap(metadata)
+	}
+	req, _ := b.client.PutObjectRequest(input)
+
+	url, err := req.Presign(time.Hour * 24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	headers := map[string]string{
+		"Content-Disposition":          contentDisposition,
+		"X-AMZ-Acl":                    S3_ACL,
+		"X-AMZ-Server-Side-Encryption": S3_SSE,
+		"X-AMZ-Storage-Class":          S3_STORAGE_CLASS,
+	}
+	for k, v := range metadata {
+		headers["X-AMZ-Meta-"+k] = v
+	}
+
+	return url, headers, nil
+}
+
+func (b *s3Backend) Get(key string, expiresIn time.Duration) (string, error) {
+	req, _ := b.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(S3_BUCKET),
+		Key:    aws.String(key),
+	})
+
+	return req.Presign(expiresIn)
+}
+
+func (b *s3Backend) Head(key string) (*HeadInfo, error) {
+	out, err := b.client.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(S3_BUCKET), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &HeadInfo{ContentLength: *out.ContentLength, LastModified: *out.LastModified}
+	if out.ContentDisposition != nil {
+		info.ContentDisposition = *out.ContentDisposition
+	}
+	if len(out.Metadata) > 0 {
+		info.Metadata = make(map[string]string, len(out.Metadata))
+		for k, v := range out.Metadata {
+			if v != nil {
+				info.Metadata[strings.ToLower(k)] = *v
+			}
+		}
+	}
+	return info, nil
+}
+
+func (b *s3Backend) List(prefix string, delimiter string, maxKeys int64, continuationToken string) ([]ListEntry, string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(S3_BUCKET),
+		MaxKeys: aws.Int64(maxKeys),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	if continuationToken != "" {
+		input.ContinuationToken = aws.String(continuationToken)
+	}
+
+	out, err := b.client.ListObjectsV2(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries := make([]ListEntry, 0, len(out.Contents))
+	for _, obj := range out.Contents {
+		entries = append(entries, ListEntry{Key: *obj.Key, LastModified: *obj.LastModified})
+	}
+
+	nextToken := ""
+	if out.IsTruncated != nil && *out.IsTruncated && out.NextContinuationToken != nil {
+		nextToken = *out.NextContinuationToken
+	}
+
+	return entries, nextToken, nil
+}
+
+func (b *s3Backend) Copy(srcKey string, dstKey string, metadata map[string]string) error {
+	input := &s3.CopyObjectInput{
+		Bucket:     aws.String(S3_BUCKET),
+		CopySource: aws.String(S3_BUCKET + "/" + srcKey),
+		Key:        aws.String(dstKey),
+	}
+	if metadata != nil {
+		input.Metadata = aws.StringMap(metadata)
+		input.MetadataDirective = aws.String(s3.MetadataDirectiveReplace)
+	}
+
+	_, err := b.client.CopyObject(input)
+	return err
+}
+
+func (b *s3Backend) Delete(key string) error {
+	_, err := b.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(S3_BUCKET),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *s3Backend) CreateMultipartUpload(key string, contentDisposition string) (string, error) {
+	out, err := b.client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		ACL:                  aws.String(S3_ACL),
+		Bucket:               aws.String(S3_BUCKET),
+		ContentDisposition:   aws.String(contentDisposition),
+		Key:                  aws.String(key),
+		ServerSideEncryption: aws.String(S3_SSE),
+		StorageClass:         aws.String(S3_STORAGE_CLASS),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *out.UploadId, nil
+}
+
+func (b *s3Backend) PresignUploadPart(key string, uploadId string, partNumber int64) (string, error) {
+	req, _ := b.client.UploadPartRequest(&s3.UploadPartInput{
+		Bucket:     aws.String(S3_BUCKET),
+		Key:        aws.String(key),
+		PartNumber: aws.Int64(partNumber),
+		UploadId:   aws.String(uploadId),
+	})
+
+	return req.Presign(time.Hour * 24)
+}
+
+func (b *s3Backend) PresignCompleteMultipartUpload(key string, uploadId string) (string, error) {
+	req, _ := b.client.CompleteMultipartUploadRequest(&s3.CompleteMultipartUploadInput{
+		Bucket:   aws.String(S3_BUCKET),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+
+	return req.Presign(time.Hour * 24)
+}
+
+func (b *s3Backend) PresignAbortMultipartUpload(key string, uploadId string) (string, error) {
+	req, _ := b.client.AbortMultipartUploadRequest(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(S3_BUCKET),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+
+	return req.Presign(time.Hour * 24)
+}
+
+func (b *s3Backend) CompleteMultipartUpload(key string, uploadId string, parts []CompletedPart) error {
+	completedParts := make([]*s3.CompletedPart, 0, len(parts))
+	for _, part := range parts {
+		completedParts = append(completedParts, &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(part.PartNumber),
+		})
+	}
+
+	_, err := b.client.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(S3_BUCKET),
+		Key:             aws.String(key),
+		UploadId:        aws.String(uploadId),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completedParts},
+	})
+	return err
+}
+
+func (b *s3Backend) ListMultipartUploads() ([]MultipartUploadEntry, error) {
+	var entries []MultipartUploadEntry
+
+	input := &s3.ListMultipartUploadsInput{Bucket: aws.String(S3_BUCKET)}
+	for {
+		out, err := b.client.ListMultipartUploads(input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, upload := range out.Uploads {
+			entries = append(entries, MultipartUploadEntry{
+				Key:       *upload.Key,
+				UploadId:  *upload.UploadId,
+				Initiated: *upload.Initiated,
+			})
+		}
+
+		if out.IsTruncated == nil || !*out.IsTruncated {
+			break
+		}
+		input.KeyMarker = out.NextKeyMarker
+		input.UploadIdMarker = out.NextUploadIdMarker
+	}
+
+	return entries, nil
+}
+
+func (b *s3Backend) AbortMultipartUpload(key string, uploadId string) error {
+	_, err := b.client.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(S3_BUCKET),
+		Key:      aws.String(key),
+		UploadId: aws.String(uploadId),
+	})
+	return err
+}
@@ -0,0 +1,137 @@
+package object
+
+import (
+	"errors"
+	"time"
+
+	"github.com/usewormhol/env"
+)
+
+// TRASH_PREFIX namespaces trashed objects away from live ones so a plain
+// List() (which excludes it via a "/" delimiter) never sees them.
+const TRASH_PREFIX = "trash/"
+
+const trashedAtMetadataKey = "trashed-at"
+
+var (
+	TRASH_LIFETIME = time.Duration(env.Int("WORMHOL_TRASH_LIFETIME_SECONDS", 60*60*24*7, env.Optional)) * time.Second
+	UNSAFE_DELETE  = env.Bool("WORMHOL_UNSAFE_DELETE", false, env.Optional)
+
+	errObjectNotTrashed           = errors.New("object not trashed")
+	errObjectTrashLifetimeElapsed = errors.New("object trash lifetime elapsed")
+)
+
+// Delete moves o to the trash, where it can be recovered with Untrash until
+// WORMHOL_TRASH_LIFETIME elapses. With WORMHOL_UNSAFE_DELETE set, it instead
+// preserves the old behavior of deleting the object immediately.
+func (o *Object) Delete() error {
+	if UNSAFE_DELETE {
+		if err := instrumentOp("delete", func() error { return getBackend().Delete(o.Key) }); err != nil {
+			return err
+		}
+		return o.purgeCache()
+	}
+
+	trashKey := TRASH_PREFIX + o.Key
+	metadata := map[string]string{trashedAtMetadataKey: time.Now().UTC().Format(time.RFC3339)}
+
+	if err := instrumentOp("copy", func() error { return getBackend().Copy(o.Key, trashKey, metadata) }); err != nil {
+		return err
+	}
+	if err := instrumentOp("delete", func() error { return getBackend().Delete(o.Key) }); err != nil {
+		return err
+	}
+
+	return o.purgeCache()
+}
+
+// Untrash restores key from the trash if it was trashed within
+// WORMHOL_TRASH_LIFETIME, undoing a prior Delete.
+func Untrash(key string) error {
+	err := objectValidate(&key, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	trashKey := TRASH_PREFIX + key
+	trashedAt, err := trashedAt(trashKey)
+	if err != nil {
+		return err
+	}
+	if time.Since(trashedAt) > TRASH_LIFETIME {
+		return errObjectTrashLifetimeElapsed
+	}
+
+	if err := instrumentOp("copy", func() error { return getBackend().Copy(trashKey, key, nil) }); err != nil {
+		return err
+	}
+
+	return instrumentOp("delete", func() error { return getBackend().Delete(trashKey) })
+}
+
+// EmptyTrash hard-deletes trashed objects whose WORMHOL_TRASH_LIFETIME has
+// elapsed, returning the number removed.
+func EmptyTrash() (int, error) {
+	n := 0
+
+	token := ""
+	for {
+		var entries []ListEntry
+		var nextToken string
+		err := instrumentOp("list", func() error {
+			var e error
+			entries, nextToken, e = getBackend().List(TRASH_PREFIX, "", S3_LIST_OBJECTS_MAX_KEYS, token)
+			return e
+		})
+		if err != nil {
+			return n, err
+		}
+
+		for _, entry := range entries {
+			trashedAt, err := trashedAt(entry.Key)
+			if err != nil {
+				// Don't treat a failure to confirm the trash lifetime
+				// (transient Head error, malformed timestamp, ...) the
+				// same as having confirmed it elapsed: that would hard
+				// delete objects we never actually verified are past
+				// their recovery window.
+				return n, err
+			}
+
+			if time.Since(trashedAt) > TRASH_LIFETIME {
+				if err := instrumentOp("delete", func() error { return getBackend().Delete(entry.Key) }); err != nil {
+					return n, err
+				}
+				n++
+			}
+		}
+
+		if nextToken == "" {
+			break
+		}
+		token = nextToken
+	}
+
+	return n, nil
+}
+
+// trashedAt reads back the trashed-at timestamp a prior Delete stamped onto
+// trashKey's metadata.
+func trashedAt(trashKey string) (time.Time, error) {
+	var head *HeadInfo
+	err := instrumentOp("head", func() error {
+		var e error
+		head, e = getBackend().Head(trashKey)
+		return e
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	raw, ok := head.Metadata[trashedAtMetadataKey]
+	if !ok {
+		return time.Time{}, errObjectNotTrashed
+	}
+
+	return time.Parse(time.RFC3339, raw)
+}